@@ -0,0 +1,53 @@
+package op
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// RequestBatch is a set of Requests decoded from a single top-level
+// JSON array, see http://www.jsonrpc.org/specification#batch.
+type RequestBatch []Request
+
+// UnmarshalRequestBatch decodes raw into a RequestBatch.
+// If raw holds a single JSON object rather than an array,
+// the result is a one-element batch, so callers don't have
+// to special-case the non-batched form of a request.
+func UnmarshalRequestBatch(raw []byte) (RequestBatch, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		req := Request{}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		return RequestBatch{req}, nil
+	}
+	batch := RequestBatch{}
+	if err := json.Unmarshal(trimmed, &batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// ResponseBatch is the set of Responses collected while dispatching
+// a RequestBatch. Requests which are Notifications don't produce
+// a Response and so are never included.
+type ResponseBatch []Response
+
+// RunForErrors calls f for every Response in the batch which holds
+// a non-nil Error, in the order the responses were appended, akin
+// to the neo-go 'AbstractResult' iteration pattern.
+func (rb ResponseBatch) RunForErrors(f func(*Error)) {
+	for _, resp := range rb {
+		if resp.Error != nil {
+			f(resp.Error)
+		}
+	}
+}
+
+// EmptyBatchError is the single Error the server must reply with
+// when it receives a syntactically valid but empty JSON array as
+// a batch request.
+func EmptyBatchError() *Error {
+	return ErrInvalidRequest
+}
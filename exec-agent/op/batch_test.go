@@ -0,0 +1,82 @@
+package op
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalRequestBatchSingleObject(t *testing.T) {
+	batch, err := UnmarshalRequestBatch([]byte(`{"jsonrpc":"2.0","method":"ping","id":"1"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(batch) != 1 || batch[0].Method != "ping" {
+		t.Fatalf("expected a one-element batch for a single object, got %+v", batch)
+	}
+}
+
+func TestUnmarshalRequestBatchArray(t *testing.T) {
+	batch, err := UnmarshalRequestBatch([]byte(`[{"jsonrpc":"2.0","method":"a","id":"1"},{"jsonrpc":"2.0","method":"b","id":"2"}]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(batch) != 2 || batch[0].Method != "a" || batch[1].Method != "b" {
+		t.Fatalf("unexpected batch contents: %+v", batch)
+	}
+}
+
+func TestDispatchBatchEmptyArrayYieldsInvalidRequestError(t *testing.T) {
+	batch, err := UnmarshalRequestBatch([]byte(`[]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	router := NewRouter()
+	responses := router.DispatchBatch(nil, batch)
+	if len(responses) != 1 {
+		t.Fatalf("expected exactly one Response for an empty batch, got %d", len(responses))
+	}
+	if responses[0].Error == nil || responses[0].Error.Code != codeInvalidRequest {
+		t.Fatalf("expected an InvalidRequest error, got %+v", responses[0].Error)
+	}
+	raw, err := json.Marshal(responses[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(string(raw), `"id":null`) {
+		t.Fatalf("expected the wire form to have an explicit 'id: null', got %s", raw)
+	}
+}
+
+func TestDispatchBatchSkipsNotifications(t *testing.T) {
+	router := NewRouter()
+	called := 0
+	router.Register(Route{Method: "ping", Handler: func(conn *Conn, rawBody []byte) (interface{}, *Error) {
+		called++
+		return "pong", nil
+	}})
+
+	batch := RequestBatch{
+		{Version: "2.0", Method: "ping"},
+		{Version: "2.0", Method: "ping", Id: []byte(`"1"`)},
+	}
+	responses := router.DispatchBatch(nil, batch)
+	if called != 2 {
+		t.Fatalf("expected both requests to be dispatched, got %d calls", called)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("expected the Notification to be suppressed from the batch, got %d responses", len(responses))
+	}
+}
+
+func TestRunForErrors(t *testing.T) {
+	batch := ResponseBatch{
+		{Id: []byte(`"1"`), Body: "ok"},
+		{Id: []byte(`"2"`), Error: ErrMethodNotFound},
+	}
+	var errs []*Error
+	batch.RunForErrors(func(e *Error) { errs = append(errs, e) })
+	if len(errs) != 1 || errs[0] != ErrMethodNotFound {
+		t.Fatalf("expected exactly the one Error-bearing Response, got %+v", errs)
+	}
+}
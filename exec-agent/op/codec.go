@@ -0,0 +1,211 @@
+package op
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Codec decouples the Request/Response/Event model from any one
+// framing or marshaling scheme, so the same route registry and
+// dispatch logic can serve operations over a websocket, a bare TCP
+// socket or a Unix domain socket, rather than being tied to
+// browser clients only.
+type Codec interface {
+
+	// ReadMessage blocks until the next Request frame is available.
+	// If ctx is done first, the underlying transport is closed to
+	// unblock the read, and ReadMessage returns the resulting error.
+	ReadMessage(ctx context.Context) (*Request, error)
+
+	// WriteResponse writes a single Response frame.
+	WriteResponse(resp *Response) error
+
+	// WriteEvent writes a single Event frame.
+	WriteEvent(event *Event) error
+}
+
+// DecodeError wraps a failure to parse a single frame's bytes into a
+// Request, as opposed to a failure of the underlying transport itself
+// (closed connection, I/O error, ctx done). Router.Serve uses this
+// distinction to reply with an ErrParse Response and keep serving the
+// connection, rather than tearing down the whole session over one
+// malformed frame.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string { return e.Err.Error() }
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// watchCancel closes closer as soon as ctx is done, so a blocking
+// Decode/Scan/Read unblocks promptly instead of waiting for the
+// transport to close on its own. Call the returned stop func once the
+// blocking call returns, successful or not, to stop watching ctx.
+func watchCancel(ctx context.Context, closer io.Closer) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			closer.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// JSONCodec reads and writes one JSON value per message on top of an
+// io.ReadWriteCloser, which is the behavior 'op' used when it only
+// spoke to websocket clients.
+type JSONCodec struct {
+	rw  io.ReadWriteCloser
+	dec *json.Decoder
+}
+
+// NewJSONCodec creates a JSONCodec reading and writing through rw.
+func NewJSONCodec(rw io.ReadWriteCloser) *JSONCodec {
+	return &JSONCodec{rw: rw, dec: json.NewDecoder(rw)}
+}
+
+func (c *JSONCodec) ReadMessage(ctx context.Context) (*Request, error) {
+	stop := watchCancel(ctx, c.rw)
+	defer stop()
+	req := &Request{}
+	if err := c.dec.Decode(req); err != nil {
+		// Only a well-formed value that doesn't fit a Request leaves the
+		// decoder able to go on with the next value. A syntax error sticks
+		// to the json.Decoder, and any other error comes from the transport,
+		// so both are returned as is.
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &DecodeError{Err: err}
+		}
+		return nil, err
+	}
+	return req, nil
+}
+
+func (c *JSONCodec) WriteResponse(resp *Response) error {
+	return json.NewEncoder(c.rw).Encode(resp)
+}
+
+func (c *JSONCodec) WriteEvent(event *Event) error {
+	return json.NewEncoder(c.rw).Encode(event)
+}
+
+// NDJSONCodec is a JSONCodec framed as newline-delimited JSON, mirroring
+// the birpc 'jsonmsg' transport: every frame is a single line, which
+// makes it usable over any io.ReadWriteCloser without a websocket
+// upgrade, e.g. a CLI tool or sidecar agent talking over a pipe.
+type NDJSONCodec struct {
+	rw   io.ReadWriteCloser
+	scan *bufio.Scanner
+}
+
+// NewNDJSONCodec creates an NDJSONCodec reading and writing through rw.
+func NewNDJSONCodec(rw io.ReadWriteCloser) *NDJSONCodec {
+	return &NDJSONCodec{rw: rw, scan: bufio.NewScanner(rw)}
+}
+
+func (c *NDJSONCodec) ReadMessage(ctx context.Context) (*Request, error) {
+	stop := watchCancel(ctx, c.rw)
+	defer stop()
+	if !c.scan.Scan() {
+		if err := c.scan.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	req := &Request{}
+	if err := json.Unmarshal(c.scan.Bytes(), req); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return req, nil
+}
+
+func (c *NDJSONCodec) WriteResponse(resp *Response) error {
+	return c.writeLine(resp)
+}
+
+func (c *NDJSONCodec) WriteEvent(event *Event) error {
+	return c.writeLine(event)
+}
+
+func (c *NDJSONCodec) writeLine(v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	raw = append(raw, '\n')
+	_, err = c.rw.Write(raw)
+	return err
+}
+
+// MsgpackCodec is a Codec which encodes every frame with MessagePack
+// rather than JSON. It takes the marshal/unmarshal
+// functions as parameters instead of importing a msgpack library
+// directly, so 'op' itself stays free of that dependency and callers
+// can bring whichever msgpack implementation they already vendor.
+//
+// Unlike JSONCodec/NDJSONCodec, MessagePack values don't carry a
+// self-describing terminator a stream reader can scan for, so each
+// frame is prefixed with its length as a 4-byte big-endian uint32.
+// This is what makes the codec safe over a real stream transport
+// (TCP/unix socket), where a single underlying Read can return a
+// partial frame or several frames coalesced together.
+type MsgpackCodec struct {
+	rw        io.ReadWriteCloser
+	r         *bufio.Reader
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+}
+
+// NewMsgpackCodec creates a MsgpackCodec reading and writing through
+// rw, using marshal/unmarshal to (de)serialize each frame.
+func NewMsgpackCodec(rw io.ReadWriteCloser, marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) *MsgpackCodec {
+	return &MsgpackCodec{rw: rw, r: bufio.NewReader(rw), marshal: marshal, unmarshal: unmarshal}
+}
+
+func (c *MsgpackCodec) ReadMessage(ctx context.Context) (*Request, error) {
+	stop := watchCancel(ctx, c.rw)
+	defer stop()
+	var length uint32
+	if err := binary.Read(c.r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(c.r, frame); err != nil {
+		return nil, err
+	}
+	req := &Request{}
+	if err := c.unmarshal(frame, req); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return req, nil
+}
+
+func (c *MsgpackCodec) WriteResponse(resp *Response) error {
+	return c.writeFrame(resp)
+}
+
+func (c *MsgpackCodec) WriteEvent(event *Event) error {
+	return c.writeFrame(event)
+}
+
+func (c *MsgpackCodec) writeFrame(v interface{}) error {
+	raw, err := c.marshal(v)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(raw)))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	_, err = c.rw.Write(raw)
+	return err
+}
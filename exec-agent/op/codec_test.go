@@ -0,0 +1,131 @@
+package op
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+// nopCloser turns an io.ReadWriter into the io.ReadWriteCloser the
+// codecs expect, for tests that don't care about Close.
+type nopCloser struct {
+	io.Reader
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// pipeReadWriteCloser glues a write side and a read side together so
+// a codec can write frames and immediately read them back, the way a
+// loopback socket would. Close closes the read side, the same way
+// closing a real connection would unblock a pending Read on it.
+type pipeReadWriteCloser struct {
+	io.Reader
+	io.Writer
+}
+
+func (p pipeReadWriteCloser) Close() error {
+	if closer, ok := p.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func TestJSONCodecRoundTrip(t *testing.T) {
+	r, w := io.Pipe()
+	codec := NewJSONCodec(pipeReadWriteCloser{Reader: r, Writer: w})
+
+	go func() {
+		json.NewEncoder(w).Encode(&Request{Version: "2.0", Method: "ping", Id: []byte(`"1"`)})
+	}()
+
+	req, err := codec.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if req.Method != "ping" {
+		t.Fatalf("unexpected method: %q", req.Method)
+	}
+}
+
+func TestNDJSONCodecRoundTrip(t *testing.T) {
+	r, w := io.Pipe()
+	codec := NewNDJSONCodec(pipeReadWriteCloser{Reader: r, Writer: w})
+
+	go func() {
+		w.Write([]byte(`{"jsonrpc":"2.0","method":"a","id":"1"}` + "\n"))
+		w.Write([]byte(`{"jsonrpc":"2.0","method":"b","id":"2"}` + "\n"))
+	}()
+
+	first, err := codec.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error on first message: %s", err)
+	}
+	second, err := codec.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error on second message: %s", err)
+	}
+	if first.Method != "a" || second.Method != "b" {
+		t.Fatalf("expected messages in order, got %q then %q", first.Method, second.Method)
+	}
+}
+
+// slowReader returns at most n bytes per Read, to exercise the
+// length-prefixed MsgpackCodec framing against a stream transport
+// that may hand back a message in several fragments.
+type slowReader struct {
+	data []byte
+	n    int
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	max := r.n
+	if max > len(p) {
+		max = len(p)
+	}
+	if max > len(r.data) {
+		max = len(r.data)
+	}
+	copied := copy(p[:max], r.data)
+	r.data = r.data[copied:]
+	return copied, nil
+}
+
+func marshalFrame(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(raw)))
+	return append(header, raw...)
+}
+
+func TestMsgpackCodecHandlesCoalescedAndFragmentedReads(t *testing.T) {
+	first := marshalFrame(t, &Request{Version: "2.0", Method: "a", Id: []byte(`"1"`)})
+	second := marshalFrame(t, &Request{Version: "2.0", Method: "b", Id: []byte(`"2"`)})
+
+	// Both frames arrive back-to-back, but the underlying transport
+	// only ever hands back 3 bytes per Read - this used to either
+	// truncate the first frame or drop the second one entirely.
+	reader := &slowReader{data: append(append([]byte{}, first...), second...), n: 3}
+	codec := NewMsgpackCodec(nopCloser{Reader: reader, Writer: io.Discard}, json.Marshal, json.Unmarshal)
+
+	got1, err := codec.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error reading first frame: %s", err)
+	}
+	got2, err := codec.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error reading second frame: %s", err)
+	}
+	if got1.Method != "a" || got2.Method != "b" {
+		t.Fatalf("expected 'a' then 'b', got %q then %q", got1.Method, got2.Method)
+	}
+}
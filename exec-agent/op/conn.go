@@ -0,0 +1,126 @@
+package op
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FrameSender pushes an outbound Request frame to the websocket client.
+// It is implemented by whatever keeps the actual websocket connection,
+// Conn only needs to hand it fully formed Requests to write out.
+type FrameSender interface {
+	SendRequest(req *Request) error
+}
+
+// Conn turns the otherwise one-way 'requests come in, responses/events
+// go out' model into a symmetric, bidirectional peer, in the style of
+// the birpc external doc: it lets the exec-agent itself originate a
+// Request towards the websocket client and wait for the matching
+// Response, e.g. to ask the client for confirmation or credentials
+// during a long-running process.
+type Conn struct {
+	clientId string
+	sender   FrameSender
+
+	idSeq   uint64
+	mu      sync.Mutex
+	pending map[string]chan *Response
+}
+
+// NewConn creates a Conn which writes outbound calls through sender.
+// clientId must be a stable, unique identifier for the underlying
+// websocket connection - it is what Subscriptions and the event
+// dispatcher key a client's subscriptions by, see ClientId.
+func NewConn(clientId string, sender FrameSender) *Conn {
+	return &Conn{
+		clientId: clientId,
+		sender:   sender,
+		pending:  make(map[string]chan *Response),
+	}
+}
+
+// ClientId returns the stable identifier this Conn was created with.
+func (c *Conn) ClientId() string {
+	return c.clientId
+}
+
+// Call sends method/params as a Request to the websocket client and
+// returns a channel which receives the correlated Response once the
+// client replies. If timeout elapses before the client responds, the
+// channel is closed without a value and the pending call is evicted.
+func (c *Conn) Call(method string, params interface{}, timeout time.Duration) (<-chan *Response, error) {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("op: could not marshal params for '%s' call: %s", method, err)
+	}
+	rawId, err := json.Marshal(fmt.Sprintf("srv-%d", atomic.AddUint64(&c.idSeq, 1)))
+	if err != nil {
+		return nil, err
+	}
+	// The pending map is keyed by the raw JSON id bytes, not the
+	// unquoted Go string - that's what actually goes out on the wire
+	// in Request.Id and what a conformant client echoes back verbatim
+	// in Response.Id, so it's the only form Resolve can reliably match.
+	id := string(rawId)
+
+	ch := make(chan *Response, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	req := &Request{
+		Version: "2.0",
+		Method:  method,
+		Id:      rawId,
+		RawBody: rawParams,
+	}
+	if err := c.sender.SendRequest(req); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	if timeout > 0 {
+		go c.evict(id, timeout)
+	}
+	return ch, nil
+}
+
+// Resolve delivers resp to the Call which is waiting for it, matched
+// by resp.Id. It reports whether a pending call was actually found,
+// so the caller can tell a reply to its own Call apart from a regular
+// Response to a client-originated Request.
+func (c *Conn) Resolve(resp *Response) bool {
+	id := string(resp.Id)
+	c.mu.Lock()
+	ch, ok := c.pending[id]
+	if ok {
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return false
+	}
+	ch <- resp
+	close(ch)
+	return true
+}
+
+// evict closes and removes the pending call identified by id if it
+// is still outstanding once timeout elapses.
+func (c *Conn) evict(id string, timeout time.Duration) {
+	time.Sleep(timeout)
+	c.mu.Lock()
+	ch, ok := c.pending[id]
+	if ok {
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+	if ok {
+		close(ch)
+	}
+}
@@ -0,0 +1,50 @@
+package op
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// echoSender simulates a websocket client that immediately echoes
+// back the id of whatever Request it receives, wrapped in a Response,
+// the same way a conformant client replying to a server-initiated
+// Call would.
+type echoSender struct {
+	conn *Conn
+}
+
+func (s *echoSender) SendRequest(req *Request) error {
+	go s.conn.Resolve(&Response{Version: "2.0", Id: req.Id, Body: "ok"})
+	return nil
+}
+
+func TestConnCallResolveCorrelation(t *testing.T) {
+	conn := NewConn("client-1", &echoSender{})
+	conn.sender.(*echoSender).conn = conn
+
+	ch, err := conn.Call("client.confirm", map[string]string{"prompt": "proceed?"}, time.Second)
+	if err != nil {
+		t.Fatalf("Call returned error: %s", err)
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed without a Response, Resolve did not correlate the reply")
+		}
+		if resp.Body != "ok" {
+			t.Fatalf("unexpected body: %v", resp.Body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Call timed out waiting for the echoed Response")
+	}
+}
+
+func TestConnResolveUnknownId(t *testing.T) {
+	conn := NewConn("client-1", &echoSender{})
+	resolved := conn.Resolve(&Response{Version: "2.0", Id: json.RawMessage(`"no-such-call"`)})
+	if resolved {
+		t.Fatal("Resolve reported success for an id with no pending Call")
+	}
+}
@@ -0,0 +1,73 @@
+package op
+
+import "fmt"
+
+// Error is returned by a Route handler when the operation execution
+// fails, and is sent back to the websocket client as 'Response.Error'.
+//
+// See http://www.jsonrpc.org/specification#error_object
+type Error struct {
+
+	// A number that indicates the error type that occurred.
+	Code int64 `json:"code"`
+
+	// A short description of the error.
+	Message string `json:"message"`
+
+	// Additional information about the error, may be omitted.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// The reserved error codes from the JSON-RPC 2.0 spec,
+// see http://www.jsonrpc.org/specification#error_object.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+
+	minServerErrorCode = -32099
+	maxServerErrorCode = -32000
+)
+
+// Sentinel errors for the codes the spec reserves for the transport
+// and dispatch layer itself, as opposed to a particular handler.
+// Use WithData to attach handler-specific debug info to a copy of one
+// of these before it is put on a Response.
+var (
+	ErrParse          = NewError(codeParseError, "Parse error")
+	ErrInvalidRequest = NewError(codeInvalidRequest, "Invalid Request")
+	ErrMethodNotFound = NewError(codeMethodNotFound, "Method not found")
+	ErrInvalidParams  = NewError(codeInvalidParams, "Invalid params")
+	ErrInternalError  = NewError(codeInternalError, "Internal error")
+)
+
+// NewError creates an Error with the given code and message.
+func NewError(code int64, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// NewServerError builds an Error using one of the codes the spec
+// reserves for implementation-defined server errors, -32000..-32099.
+// It fails if code is outside of that range.
+func NewServerError(code int64, message string, data interface{}) (*Error, error) {
+	if code < minServerErrorCode || code > maxServerErrorCode {
+		return nil, fmt.Errorf("op: server error code must be in range %d..%d, got %d",
+			minServerErrorCode, maxServerErrorCode, code)
+	}
+	return &Error{Code: code, Message: message, Data: data}, nil
+}
+
+// WithData returns a copy of e with Data set, leaving e itself
+// untouched so that the package-level sentinel errors can be shared
+// safely between goroutines.
+func (e *Error) WithData(data interface{}) *Error {
+	return &Error{Code: e.Code, Message: e.Message, Data: data}
+}
+
+// Error implements the built-in error interface so that op.Error
+// can be returned and handled like any other error.
+func (e *Error) Error() string {
+	return e.Message
+}
@@ -0,0 +1,22 @@
+package op
+
+import "testing"
+
+func TestNewServerErrorValidatesRange(t *testing.T) {
+	if _, err := NewServerError(-32050, "busy", nil); err != nil {
+		t.Fatalf("expected -32050 to be a valid server error code, got: %s", err)
+	}
+	if _, err := NewServerError(-31000, "out of range", nil); err == nil {
+		t.Fatal("expected a code outside -32099..-32000 to be rejected")
+	}
+}
+
+func TestWithDataDoesNotMutateSentinel(t *testing.T) {
+	withData := ErrMethodNotFound.WithData("process.start")
+	if ErrMethodNotFound.Data != nil {
+		t.Fatal("WithData must not mutate the shared sentinel error")
+	}
+	if withData.Code != ErrMethodNotFound.Code || withData.Data != "process.start" {
+		t.Fatalf("unexpected copy: %+v", withData)
+	}
+}
@@ -0,0 +1,25 @@
+package op
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ValidateId checks that raw is a value the JSON-RPC 2.0 spec allows
+// for an 'id' field - a string, a number or null - and rejects
+// anything else (objects, arrays, booleans).
+func ValidateId(raw json.RawMessage) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return fmt.Errorf("op: id is not valid JSON: %s", err)
+	}
+	switch v.(type) {
+	case nil, string, float64:
+		return nil
+	default:
+		return fmt.Errorf("op: id must be a string, a number or null, got %T", v)
+	}
+}
@@ -59,12 +59,24 @@ type Request struct {
 	// It is preferable to specify identifier for those calls which may
 	// either validate data, or produce such information which can't be
 	// identified by itself.
-	Id interface{} `json:"id"`
+	//
+	// Id is kept as raw JSON rather than interface{} so that an absent
+	// 'id' field (a Notification, see IsNotification) can be told apart
+	// from an explicit 'id: null', and so that numeric ids survive the
+	// round trip without Go's default float64 coercion.
+	Id json.RawMessage `json:"id,omitempty"`
 
 	// Request data, parameters which are needed for operation execution.
 	RawBody json.RawMessage `json:"params"`
 }
 
+// IsNotification reports whether this Request is a JSON-RPC 2.0
+// Notification, i.e. the 'id' field is absent from the request entirely.
+// A Notification must never receive a Response.
+func (r *Request) IsNotification() bool {
+	return len(r.Id) == 0
+}
+
 // A message from the server to the client,
 // which represents the result of the certain operation execution.
 // The result is sent to the client only once per operation.
@@ -75,7 +87,10 @@ type Response struct {
 
 	// The operation call identifier, will be set only
 	// if the operation contains it. See 'op.Call.Id'
-	Id interface{} `json:"id"`
+	//
+	// Kept as raw JSON, same as Request.Id, so the client's original
+	// id bytes - string, number or null - are preserved verbatim.
+	Id json.RawMessage `json:"id,omitempty"`
 
 	// The actual result data, the operation execution result.
 	Body interface{} `json:"result,omitempty"`
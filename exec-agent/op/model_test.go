@@ -0,0 +1,52 @@
+package op
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestIsNotificationWhenIdAbsent(t *testing.T) {
+	req := Request{}
+	if err := json.Unmarshal([]byte(`{"jsonrpc":"2.0","method":"ping"}`), &req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !req.IsNotification() {
+		t.Fatal("expected a Request with no 'id' field to be a Notification")
+	}
+}
+
+func TestRequestIsNotNotificationWhenIdExplicitNull(t *testing.T) {
+	req := Request{}
+	if err := json.Unmarshal([]byte(`{"jsonrpc":"2.0","method":"ping","id":null}`), &req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if req.IsNotification() {
+		t.Fatal("'id: null' must be distinct from an absent id, and is not a Notification")
+	}
+}
+
+func TestRequestIdPreservesNumberBytesVerbatim(t *testing.T) {
+	req := Request{}
+	if err := json.Unmarshal([]byte(`{"jsonrpc":"2.0","method":"ping","id":9007199254740993}`), &req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(req.Id) != "9007199254740993" {
+		t.Fatalf("expected the id bytes to round-trip exactly, got %q (float64 coercion would lose precision here)", req.Id)
+	}
+}
+
+func TestValidateIdAcceptsStringNumberAndNull(t *testing.T) {
+	for _, raw := range []string{`"abc"`, `123`, `null`, ``} {
+		if err := ValidateId([]byte(raw)); err != nil {
+			t.Errorf("expected %q to be a valid id, got error: %s", raw, err)
+		}
+	}
+}
+
+func TestValidateIdRejectsObjectsAndArrays(t *testing.T) {
+	for _, raw := range []string{`{"a":1}`, `[1,2]`, `true`} {
+		if err := ValidateId([]byte(raw)); err == nil {
+			t.Errorf("expected %q to be rejected as an id", raw)
+		}
+	}
+}
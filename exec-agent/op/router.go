@@ -0,0 +1,160 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// Route couples a method name to the handler which performs it. conn
+// is the bidirectional peer the Request arrived on, so a handler can
+// itself call back into the client mid-execution, see Conn.Call.
+type Route struct {
+	Method  string
+	Handler func(conn *Conn, rawBody []byte) (interface{}, *Error)
+}
+
+// Router is the method registry 'op' dispatches Requests through: it
+// ties together id validation, Notification handling and the error
+// taxonomy so a transport only has to call Dispatch/DispatchBatch
+// once it has decoded a Request/RequestBatch off the wire.
+//
+// NewRouter also registers the reserved 'subscribe'/'unsubscribe'
+// methods, so they are genuinely handled internally by the server
+// rather than just reserved by convention, see Subscriptions.
+type Router struct {
+	mu     sync.RWMutex
+	routes map[string]Route
+	subs   *Subscriptions
+}
+
+// NewRouter creates a Router with 'subscribe' and 'unsubscribe'
+// already registered against its own Subscriptions registry.
+func NewRouter() *Router {
+	r := &Router{routes: make(map[string]Route), subs: NewSubscriptions()}
+	r.Register(Route{Method: MethodSubscribe, Handler: r.handleSubscribe})
+	r.Register(Route{Method: MethodUnsubscribe, Handler: r.handleUnsubscribe})
+	return r
+}
+
+// Subscriptions returns the registry 'subscribe'/'unsubscribe' manage,
+// so the event dispatcher can call Interested/Deliver against the
+// same subscriptions clients actually hold.
+func (r *Router) Subscriptions() *Subscriptions {
+	return r.subs
+}
+
+func (r *Router) handleSubscribe(conn *Conn, rawBody []byte) (interface{}, *Error) {
+	if conn == nil {
+		return nil, ErrInvalidParams.WithData("subscribe requires an identified client connection")
+	}
+	params := SubscribeParams{}
+	if err := json.Unmarshal(rawBody, &params); err != nil {
+		return nil, ErrInvalidParams.WithData(err.Error())
+	}
+	return r.subs.Subscribe(conn.ClientId(), params), nil
+}
+
+func (r *Router) handleUnsubscribe(conn *Conn, rawBody []byte) (interface{}, *Error) {
+	if conn == nil {
+		return nil, ErrInvalidParams.WithData("unsubscribe requires an identified client connection")
+	}
+	params := UnsubscribeParams{}
+	if err := json.Unmarshal(rawBody, &params); err != nil {
+		return nil, ErrInvalidParams.WithData(err.Error())
+	}
+	if !r.subs.Unsubscribe(conn.ClientId(), params.SubscriptionId) {
+		return nil, ErrInvalidParams.WithData("no such subscription: " + params.SubscriptionId)
+	}
+	return true, nil
+}
+
+// Register adds route to the registry, replacing any existing route
+// for the same Method.
+func (r *Router) Register(route Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[route.Method] = route
+}
+
+// Dispatch routes a single Request to its handler and builds the
+// matching Response. It returns nil for a Notification (IsNotification),
+// which per spec must never receive a Response.
+func (r *Router) Dispatch(conn *Conn, req *Request) *Response {
+	if err := ValidateId(req.Id); err != nil {
+		if req.IsNotification() {
+			return nil
+		}
+		return &Response{Version: "2.0", Id: req.Id, Error: ErrInvalidRequest.WithData(err.Error())}
+	}
+
+	r.mu.RLock()
+	route, ok := r.routes[req.Method]
+	r.mu.RUnlock()
+
+	var body interface{}
+	var rpcErr *Error
+	if !ok {
+		rpcErr = ErrMethodNotFound.WithData(req.Method)
+	} else {
+		body, rpcErr = route.Handler(conn, req.RawBody)
+	}
+
+	if req.IsNotification() {
+		return nil
+	}
+	return &Response{Version: "2.0", Id: req.Id, Body: body, Error: rpcErr}
+}
+
+// DispatchBatch routes every Request in batch through Dispatch and
+// collects the non-nil Responses, in the order batch was given. An
+// empty batch yields the single InvalidRequest error object the spec
+// requires instead of an empty array.
+func (r *Router) DispatchBatch(conn *Conn, batch RequestBatch) ResponseBatch {
+	if len(batch) == 0 {
+		// The id can't be determined for this error, so the spec
+		// requires an explicit 'id: null' rather than an omitted
+		// 'id' field.
+		return ResponseBatch{{Version: "2.0", Id: json.RawMessage("null"), Error: EmptyBatchError()}}
+	}
+	responses := make(ResponseBatch, 0, len(batch))
+	for i := range batch {
+		if resp := r.Dispatch(conn, &batch[i]); resp != nil {
+			responses = append(responses, *resp)
+		}
+	}
+	return responses
+}
+
+// Serve reads Requests off codec in a loop and dispatches each through
+// r, writing the Response back through the same codec, so the router
+// can serve any transport a Codec has been written for - a websocket,
+// a bare TCP socket, or a Unix domain socket - without change. A frame
+// that fails to parse (a *DecodeError) doesn't end the session: Serve
+// replies with an ErrParse Response for that one frame and keeps
+// going. Serve returns once ReadMessage returns any other error, e.g.
+// ctx is done or the underlying transport is closed.
+func (r *Router) Serve(ctx context.Context, codec Codec, conn *Conn) error {
+	for {
+		req, err := codec.ReadMessage(ctx)
+		if err != nil {
+			var decodeErr *DecodeError
+			if errors.As(err, &decodeErr) {
+				resp := &Response{Version: "2.0", Id: json.RawMessage("null"), Error: ErrParse.WithData(decodeErr.Error())}
+				if werr := codec.WriteResponse(resp); werr != nil {
+					return werr
+				}
+				continue
+			}
+			return err
+		}
+		resp := r.Dispatch(conn, req)
+		if resp == nil {
+			continue
+		}
+		if err := codec.WriteResponse(resp); err != nil {
+			return err
+		}
+	}
+}
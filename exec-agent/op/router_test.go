@@ -0,0 +1,114 @@
+package op
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouterServeDispatchesAndRespondsOverNDJSONCodec(t *testing.T) {
+	r, w := io.Pipe()
+	respR, respW := io.Pipe()
+	codec := NewNDJSONCodec(pipeReadWriteCloser{Reader: r, Writer: respW})
+
+	router := NewRouter()
+	router.Register(Route{Method: "ping", Handler: func(conn *Conn, rawBody []byte) (interface{}, *Error) {
+		return "pong", nil
+	}})
+
+	done := make(chan error, 1)
+	go func() { done <- router.Serve(context.Background(), codec, nil) }()
+
+	go w.Write([]byte(`{"jsonrpc":"2.0","method":"ping","id":"1"}` + "\n"))
+
+	respCh := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 256)
+		n, _ := respR.Read(buf)
+		respCh <- string(buf[:n])
+	}()
+
+	select {
+	case resp := <-respCh:
+		if !strings.Contains(resp, `"result":"pong"`) {
+			t.Fatalf("expected a pong result in the Response, got %s", resp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not produce a Response within 1s")
+	}
+
+	w.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the transport closed")
+	}
+}
+
+func TestRouterServeRepliesErrParseAndKeepsServing(t *testing.T) {
+	r, w := io.Pipe()
+	respR, respW := io.Pipe()
+	codec := NewNDJSONCodec(pipeReadWriteCloser{Reader: r, Writer: respW})
+
+	router := NewRouter()
+	router.Register(Route{Method: "ping", Handler: func(conn *Conn, rawBody []byte) (interface{}, *Error) {
+		return "pong", nil
+	}})
+
+	done := make(chan error, 1)
+	go func() { done <- router.Serve(context.Background(), codec, nil) }()
+
+	go func() {
+		w.Write([]byte(`{not valid json` + "\n"))
+		w.Write([]byte(`{"jsonrpc":"2.0","method":"ping","id":"1"}` + "\n"))
+	}()
+
+	readResponse := func() string {
+		buf := make([]byte, 256)
+		n, err := respR.Read(buf)
+		if err != nil {
+			t.Fatalf("unexpected error reading response: %s", err)
+		}
+		return string(buf[:n])
+	}
+
+	first := readResponse()
+	if !strings.Contains(first, `"code":-32700`) {
+		t.Fatalf("expected the malformed frame to produce an ErrParse Response, got %s", first)
+	}
+
+	second := readResponse()
+	if !strings.Contains(second, `"result":"pong"`) {
+		t.Fatalf("expected Serve to keep serving after the malformed frame, got %s", second)
+	}
+
+	w.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the transport closed")
+	}
+}
+
+func TestRouterServeUnblocksOnContextCancellation(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	codec := NewNDJSONCodec(pipeReadWriteCloser{Reader: r, Writer: io.Discard})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewRouter().Serve(ctx, codec, nil) }()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected Serve to return an error once ctx is canceled")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not unblock within 1s of ctx cancellation")
+	}
+}
@@ -0,0 +1,166 @@
+package op
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Reserved methods NewRouter registers against its own Subscriptions
+// registry rather than leaving for a Route to handle, turning 'Event'
+// from an unconditional broadcast into a selective pub/sub channel.
+const (
+	MethodSubscribe   = "subscribe"
+	MethodUnsubscribe = "unsubscribe"
+)
+
+// SubscribeParams is the expected 'params' body of a 'subscribe' Request.
+type SubscribeParams struct {
+
+	// EventType this subscription is interested in, e.g. 'process.stdout'.
+	EventType string `json:"event_type"`
+
+	// Filter restricts the subscription to events whose body fields
+	// match all of the given field-equality predicates, e.g.
+	// {"pid": 123} to watch a particular process only.
+	Filter map[string]interface{} `json:"filter,omitempty"`
+}
+
+// UnsubscribeParams is the expected 'params' body of an 'unsubscribe' Request.
+type UnsubscribeParams struct {
+
+	// SubscriptionId identifies the subscription to cancel, as
+	// previously returned in the 'subscribe' Response body.
+	SubscriptionId string `json:"subscription_id"`
+}
+
+// subscription is a single client's interest in a given EventType,
+// optionally narrowed down by Filter.
+type subscription struct {
+	id        string
+	eventType string
+	filter    map[string]interface{}
+}
+
+// matches reports whether event satisfies this subscription: the
+// event type must match exactly, and every field in the filter must
+// be present in the event's body with an equal value.
+func (s *subscription) matches(event *Event) bool {
+	if event.EventType != s.eventType {
+		return false
+	}
+	if len(s.filter) == 0 {
+		return true
+	}
+	raw, err := json.Marshal(event.Body)
+	if err != nil {
+		return false
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+	for key, want := range s.filter {
+		got, present := fields[key]
+		// Filter values and decoded event fields can be arrays or
+		// objects (e.g. {"tags": ["a"]}), which decode to
+		// []interface{}/map[string]interface{} and panic on '!=' -
+		// reflect.DeepEqual handles those as well as scalars.
+		if !present || !reflect.DeepEqual(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscriptions tracks, per websocket client, which Event types (and
+// optional filters) that client is currently interested in, and is
+// used by the event dispatcher to decide whether a given client
+// should receive a given Event at all.
+type Subscriptions struct {
+	idSeq uint64
+
+	mu       sync.RWMutex
+	byClient map[string]map[string]*subscription
+}
+
+// NewSubscriptions creates an empty subscription registry.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{byClient: make(map[string]map[string]*subscription)}
+}
+
+// Subscribe registers a new subscription for clientId and returns its
+// generated subscription id, to be returned as the 'subscribe' Response body.
+func (s *Subscriptions) Subscribe(clientId string, params SubscribeParams) string {
+	id := fmt.Sprintf("sub-%d", atomic.AddUint64(&s.idSeq, 1))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs, ok := s.byClient[clientId]
+	if !ok {
+		subs = make(map[string]*subscription)
+		s.byClient[clientId] = subs
+	}
+	subs[id] = &subscription{id: id, eventType: params.EventType, filter: params.Filter}
+	return id
+}
+
+// Unsubscribe removes a previously registered subscription. It reports
+// whether a subscription with that id actually existed for clientId.
+func (s *Subscriptions) Unsubscribe(clientId, subscriptionId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs, ok := s.byClient[clientId]
+	if !ok {
+		return false
+	}
+	if _, ok := subs[subscriptionId]; !ok {
+		return false
+	}
+	delete(subs, subscriptionId)
+	return true
+}
+
+// Close drops every subscription held by clientId, and must be called
+// once the client's websocket connection is closed.
+func (s *Subscriptions) Close(clientId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byClient, clientId)
+}
+
+// Interested reports whether clientId holds a subscription matching
+// event, i.e. whether the event dispatcher should actually deliver
+// event to that client.
+func (s *Subscriptions) Interested(clientId string, event *Event) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sub := range s.byClient[clientId] {
+		if sub.matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Deliver is the event dispatcher: it writes event, through the Codec
+// registered for it, to every client in clients which currently holds
+// a matching subscription, and skips the rest. It returns the write
+// errors keyed by client id, if any.
+func (s *Subscriptions) Deliver(event *Event, clients map[string]Codec) map[string]error {
+	var errs map[string]error
+	for clientId, codec := range clients {
+		if !s.Interested(clientId, event) {
+			continue
+		}
+		if err := codec.WriteEvent(event); err != nil {
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[clientId] = err
+		}
+	}
+	return errs
+}
@@ -0,0 +1,156 @@
+package op
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubscriptionMatchesArrayFilterDoesNotPanic(t *testing.T) {
+	subs := NewSubscriptions()
+	subs.Subscribe("client-1", SubscribeParams{
+		EventType: "process.stdout",
+		Filter:    map[string]interface{}{"tags": []interface{}{"a"}},
+	})
+
+	event := &Event{EventType: "process.stdout", Body: &taggedEventBody{Tags: []string{"a"}}}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Interested panicked on a non-scalar filter value: %v", r)
+		}
+	}()
+	if !subs.Interested("client-1", event) {
+		t.Fatal("expected subscription with matching array filter to match")
+	}
+}
+
+func TestSubscriptionFilterMismatchExcludes(t *testing.T) {
+	subs := NewSubscriptions()
+	subs.Subscribe("client-1", SubscribeParams{
+		EventType: "process.stdout",
+		Filter:    map[string]interface{}{"pid": float64(123)},
+	})
+
+	event := &Event{EventType: "process.stdout", Body: &pidEventBody{Pid: 456}}
+	if subs.Interested("client-1", event) {
+		t.Fatal("expected subscription to exclude event with a non-matching filter field")
+	}
+}
+
+func TestSubscriptionsDeliverOnlySendsToInterestedClients(t *testing.T) {
+	subs := NewSubscriptions()
+	subs.Subscribe("client-1", SubscribeParams{EventType: "process.stdout"})
+
+	event := &Event{EventType: "process.stdout", Body: &taggedEventBody{}}
+	c1 := &recordingCodec{}
+	c2 := &recordingCodec{}
+
+	subs.Deliver(event, map[string]Codec{"client-1": c1, "client-2": c2})
+
+	if c1.events != 1 {
+		t.Fatalf("expected subscribed client to receive 1 event, got %d", c1.events)
+	}
+	if c2.events != 0 {
+		t.Fatalf("expected unsubscribed client to receive 0 events, got %d", c2.events)
+	}
+}
+
+func TestSubscriptionsUnsubscribeAndClose(t *testing.T) {
+	subs := NewSubscriptions()
+	id := subs.Subscribe("client-1", SubscribeParams{EventType: "process.stdout"})
+
+	event := &Event{EventType: "process.stdout", Body: &taggedEventBody{}}
+	if !subs.Interested("client-1", event) {
+		t.Fatal("expected fresh subscription to match")
+	}
+
+	if !subs.Unsubscribe("client-1", id) {
+		t.Fatal("Unsubscribe reported no such subscription right after Subscribe")
+	}
+	if subs.Interested("client-1", event) {
+		t.Fatal("expected no match after Unsubscribe")
+	}
+
+	subs.Subscribe("client-1", SubscribeParams{EventType: "process.stdout"})
+	subs.Close("client-1")
+	if subs.Interested("client-1", event) {
+		t.Fatal("expected no match after Close")
+	}
+}
+
+func TestRouterDispatchSubscribeAndUnsubscribe(t *testing.T) {
+	router := NewRouter()
+	conn := NewConn("client-1", &echoSender{})
+
+	subscribeReq := &Request{
+		Version: "2.0",
+		Method:  MethodSubscribe,
+		Id:      []byte(`"1"`),
+		RawBody: []byte(`{"event_type":"process.stdout","filter":{"pid":123}}`),
+	}
+	resp := router.Dispatch(conn, subscribeReq)
+	if resp == nil || resp.Error != nil {
+		t.Fatalf("expected subscribe to succeed, got %+v", resp)
+	}
+	subscriptionId, ok := resp.Body.(string)
+	if !ok || subscriptionId == "" {
+		t.Fatalf("expected subscribe to return a subscription id, got %+v", resp.Body)
+	}
+
+	matching := &Event{EventType: "process.stdout", Body: &pidEventBody{Pid: 123}}
+	if !router.Subscriptions().Interested("client-1", matching) {
+		t.Fatal("expected the subscribed client to be interested in a matching event")
+	}
+	nonMatching := &Event{EventType: "process.stdout", Body: &pidEventBody{Pid: 456}}
+	if router.Subscriptions().Interested("client-1", nonMatching) {
+		t.Fatal("expected the subscribed client to be excluded by its pid filter")
+	}
+
+	unsubscribeReq := &Request{
+		Version: "2.0",
+		Method:  MethodUnsubscribe,
+		Id:      []byte(`"2"`),
+		RawBody: []byte(`{"subscription_id":"` + subscriptionId + `"}`),
+	}
+	resp = router.Dispatch(conn, unsubscribeReq)
+	if resp == nil || resp.Error != nil {
+		t.Fatalf("expected unsubscribe to succeed, got %+v", resp)
+	}
+	if router.Subscriptions().Interested("client-1", matching) {
+		t.Fatal("expected no interest left after unsubscribe")
+	}
+}
+
+func TestRouterDispatchSubscribeWithoutConnIsRejected(t *testing.T) {
+	router := NewRouter()
+	req := &Request{Version: "2.0", Method: MethodSubscribe, Id: []byte(`"1"`), RawBody: []byte(`{"event_type":"x"}`)}
+	resp := router.Dispatch(nil, req)
+	if resp == nil || resp.Error == nil {
+		t.Fatalf("expected subscribe without a Conn to fail, got %+v", resp)
+	}
+}
+
+type taggedEventBody struct {
+	EventBody
+	Tags []string `json:"tags"`
+}
+
+type pidEventBody struct {
+	EventBody
+	Pid int `json:"pid"`
+}
+
+type recordingCodec struct {
+	events int
+}
+
+func (c *recordingCodec) ReadMessage(_ context.Context) (*Request, error) {
+	return nil, nil
+}
+
+func (c *recordingCodec) WriteResponse(*Response) error { return nil }
+
+func (c *recordingCodec) WriteEvent(*Event) error {
+	c.events++
+	return nil
+}